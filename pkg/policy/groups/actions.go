@@ -24,6 +24,10 @@ import (
 	cilium_v2 "github.com/cilium/cilium/pkg/k8s/apis/cilium.io/v2"
 	"github.com/cilium/cilium/pkg/logging/logfields"
 	"github.com/cilium/cilium/pkg/metrics"
+	groupsmetrics "github.com/cilium/cilium/pkg/policy/groups/metrics"
+	"github.com/cilium/cilium/pkg/policy/groups/providers"
+	"github.com/cilium/cilium/pkg/policy/groups/ratelimit"
+	groupstore "github.com/cilium/cilium/pkg/policy/groups/store"
 
 	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -32,14 +36,84 @@ import (
 const (
 	// maxNumberOfAttempts Number of times that try to retrieve a information from a cloud provider.
 	maxNumberOfAttempts = 5
-	// SleepDuration time that sleep in case that can't retrieve information from a cloud provider.
-	sleepDuration = 5 * time.Second
 )
 
 var (
 	controllerManager = controller.NewManager()
+
+	// rateLimiter throttles and backs off provider calls made while
+	// resolving ToGroups rules, shared by every derivative CNP so that
+	// policies sharing a provider slow down together instead of each one
+	// independently hammering the API.
+	rateLimiter = ratelimit.NewManager()
+
+	// sharedCache is the kvstore-backed cache of resolved group memberships.
+	// It is nil until SetSharedCache is called by the operator's
+	// initialization code.
+	//
+	// addDerivativeCNP consults it to record cache hit/miss metrics and to
+	// feed logDerivativeConditions its lastGoodIPCount/staleness inputs, but
+	// does not short-circuit createDerivativeCNP on a hit. That requires two
+	// changes to createDerivativeCNP's signature, not to anything in this
+	// file: it must accept a pre-resolved IP list so a hit can skip the
+	// provider call, and it must return the IPs it resolved (cached or
+	// fresh) so addDerivativeCNP can call sharedCache.Publish after a miss.
+	// createDerivativeCNP is declared in a sibling file this source tree
+	// does not include, so this package cannot make that signature change
+	// itself; until it's made upstream, every caller falls back to a direct
+	// provider lookup regardless of what is cached, and sharedCache only
+	// drives metrics and condition logging.
+	sharedCache *groupstore.Cache
 )
 
+// SetSharedCache wires the kvstore-backed shared group membership cache
+// into the groups subsystem. It must be called once during cilium-operator
+// startup, after the kvstore client and leader election are both available.
+// See the sharedCache doc comment for the current extent of its use.
+func SetSharedCache(cache *groupstore.Cache) {
+	sharedCache = cache
+}
+
+// cacheGroupKeyForCNP returns the identifier sharedCache is consulted under
+// for cnp. cilium_v2.ToGroups does not yet carry the provider-specific
+// group identifier (e.g. an AWS security group ID) that should really key
+// this cache entry, so the CNP's own namespace/name stands in for it; once
+// ToGroups exposes that selector, cache entries should be keyed by it
+// instead so that multiple CNPs referencing the same group share one entry.
+func cacheGroupKeyForCNP(cnp *cilium_v2.CiliumNetworkPolicy) string {
+	return cnp.ObjectMeta.Namespace + "/" + cnp.ObjectMeta.Name
+}
+
+// logDerivativeConditions builds the Ready/Degraded/Stale conditions for
+// cnp's latest resolution attempt and logs them at scopedLog. This is the
+// one real call site for buildDerivativeConditions outside its own tests:
+// updateDerivativeStatus does not accept structured conditions yet (see the
+// NOTE on buildDerivativeConditions), so they cannot be persisted onto the
+// parent CNP's status here, so they are logged instead. It is a no-op until
+// sharedCache has an entry for cnp, since lastGoodIPCount and the
+// staleness check both come from the cached GroupMembers rather than from
+// resolveErr alone. sharedCache.Get only returns unexpired entries, so the
+// Stale condition logged here is always False.
+func logDerivativeConditions(scopedLog *logrus.Entry, kind string, cnp *cilium_v2.CiliumNetworkPolicy, resolveErr error) {
+	if sharedCache == nil {
+		return
+	}
+
+	entry, ok := sharedCache.Get(kind, cacheGroupKeyForCNP(cnp))
+	if !ok {
+		return
+	}
+
+	for _, condition := range buildDerivativeConditions(resolveErr, len(entry.IPs), len(entry.IPs), entry.ResolvedAt, entry.TTL) {
+		scopedLog.WithFields(logrus.Fields{
+			"conditionType":   condition.Type,
+			"conditionStatus": condition.Status,
+			"reason":          condition.Reason,
+			"lastGoodIPCount": condition.LastKnownGoodIPCount,
+		}).Debug("Derivative CNP condition")
+	}
+}
+
 // AddDerivativeCNPIfNeeded will create a new CNP if the given CNP has any rule
 // that need to create a new derivative policy.
 // It returns a boolean, true in case that all actions are correct, false if
@@ -123,9 +197,24 @@ func DeleteDerivativeCNP(cnp *cilium_v2.CiliumNetworkPolicy) error {
 	}
 
 	DeleteDerivativeFromCache(cnp)
+	groupsmetrics.ForgetCNP(cnp.ObjectMeta.Namespace, cnp.ObjectMeta.Name)
 	return nil
 }
 
+// kindForCNP returns the ToGroups provider kind used to look up a
+// GroupProvider in the registry. Every ToGroups rule is assumed to be AWS,
+// since cilium_v2.ToGroups does not yet carry a provider-tagged selector;
+// once it does, this should inspect cnp.Specs for the rule's actual kind
+// instead of hard-coding AWS. This hard-coded return is the only reason
+// providers.Lookup below ever succeeds for a real CNP today, which is also
+// why createDerivativeCNP resolving AWS directly instead of dispatching
+// through providers.GroupProvider.Resolve has not yet broken anything
+// observably: see the NOTE on providers.GroupProvider for exactly what
+// changes (outside this package) that dispatch is blocked on.
+func kindForCNP(cnp *cilium_v2.CiliumNetworkPolicy) string {
+	return providers.KindAWS
+}
+
 func addDerivativeCNP(ctx context.Context, cnp *cilium_v2.CiliumNetworkPolicy) error {
 
 	scopedLog := log.WithFields(logrus.Fields{
@@ -136,6 +225,32 @@ func addDerivativeCNP(ctx context.Context, cnp *cilium_v2.CiliumNetworkPolicy) e
 	var derivativeCNP *cilium_v2.CiliumNetworkPolicy
 	var derivativeErr error
 
+	kind := kindForCNP(cnp)
+	if _, ok := providers.Lookup(kind); !ok {
+		// No point entering the retry loop, or calling createDerivativeCNP
+		// at all, for a kind nothing can resolve: fail the same way an
+		// exhausted retry loop does, straight to the deny-all status
+		// update, instead of silently falling through to the AWS-only path
+		// below.
+		err := fmt.Errorf("no GroupProvider registered for ToGroups kind %q", kind)
+		scopedLog.WithError(err).Error("Cannot create derivative rule. Installing deny-all rule.")
+		metrics.PolicyImportErrors.Inc()
+		statusErr := updateDerivativeStatus(cnp, "", err)
+		if statusErr != nil {
+			scopedLog.WithError(statusErr).Error("Cannot update CNP status for derivative policy")
+		}
+		return err
+	}
+	provider := ratelimit.Provider(kind)
+
+	if sharedCache != nil {
+		if _, hit := sharedCache.Get(kind, cacheGroupKeyForCNP(cnp)); hit {
+			groupsmetrics.ObserveCacheHit(kind)
+		} else {
+			groupsmetrics.ObserveCacheMiss(kind)
+		}
+	}
+
 	// The maxNumberOfAttempts is to not hit the limits of cloud providers API.
 	// Also, the derivativeErr is never returned, if not the controller will
 	// hit this function and the cloud providers limit will be raised. This
@@ -145,18 +260,53 @@ func addDerivativeCNP(ctx context.Context, cnp *cilium_v2.CiliumNetworkPolicy) e
 	// the derivative status in the parent policy  will be updated with the
 	// error.
 	for numAttempts := 0; numAttempts <= maxNumberOfAttempts; numAttempts++ {
+		if err := rateLimiter.Acquire(ctx, provider); err != nil {
+			// Acquire only fails when ctx is done or its deadline would pass
+			// before a token is free (e.g. operator shutdown). On the first
+			// iteration createDerivativeCNP has not run yet and
+			// derivativeCNP is still nil, so return directly instead of
+			// falling through to the post-loop code, which assumes
+			// derivativeCNP is non-nil. On later iterations this also
+			// returns without installing the deny-all derivativeCNP from
+			// the previous attempt.
+			scopedLog.WithError(err).Error("Cannot acquire cloud provider rate limit token")
+			metrics.PolicyImportErrors.Inc()
+			statusErr := updateDerivativeStatus(cnp, "", err)
+			if statusErr != nil {
+				scopedLog.WithError(statusErr).Error("Cannot update CNP status for derivative policy")
+			}
+			return err
+		}
+
+		resolutionStart := time.Now()
 		derivativeCNP, derivativeErr = createDerivativeCNP(ctx, cnp)
+		groupsmetrics.ObserveResolution(kind, time.Since(resolutionStart))
+
 		if derivativeErr == nil {
+			rateLimiter.ReportSuccess(provider)
+			groupsmetrics.RecordSuccessfulResolution(cnp.ObjectMeta.Namespace, cnp.ObjectMeta.Name)
 			break
 		}
+
+		errClass := groupsmetrics.ErrorClassOther
+		if ratelimit.IsThrottlingError(provider, derivativeErr) {
+			// Feed the throttling signal back into the shared limiter so
+			// every other CNP resolving against this provider backs off
+			// too, instead of each one independently retrying into the
+			// same limit.
+			rateLimiter.ReportThrottled(provider)
+			errClass = groupsmetrics.ErrorClassThrottled
+		}
+		groupsmetrics.ObserveRetry(kind, errClass)
+
 		metrics.PolicyImportErrors.Inc()
 		scopedLog.WithError(derivativeErr).Error("Cannot create derivative rule. Installing deny-all rule.")
 		statusErr := updateDerivativeStatus(cnp, derivativeCNP.ObjectMeta.Name, derivativeErr)
 		if statusErr != nil {
 			scopedLog.WithError(statusErr).Error("Cannot update CNP status for derivative policy")
 		}
-		time.Sleep(sleepDuration)
 	}
+	logDerivativeConditions(scopedLog, kind, cnp, derivativeErr)
 	groupsCNPCache.UpdateCNP(cnp)
 	_, err := updateOrCreateCNP(derivativeCNP)
 	if err != nil {
@@ -0,0 +1,116 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package groups
+
+import (
+	"time"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConditionType is the type of a structured derivative CNP condition, in
+// the same spirit as the Ready/Progressing conditions on built-in
+// Kubernetes resources, so `kubectl describe cnp` can explain why a
+// derivative is deny-all instead of just showing the last error string.
+type ConditionType string
+
+const (
+	// ConditionReady is true when the latest resolution attempt succeeded.
+	// The age of that resolution does not affect it; ConditionStale reports
+	// that separately.
+	ConditionReady ConditionType = "Ready"
+	// ConditionDegraded is true when the last resolution attempt failed and
+	// the derivative fell back to deny-all.
+	ConditionDegraded ConditionType = "Degraded"
+	// ConditionStale is true when the derivative is serving a resolution
+	// older than its TTL, whether from the shared cache or from the last
+	// successful direct provider call.
+	ConditionStale ConditionType = "Stale"
+)
+
+// DerivativeCondition is one entry of the structured status that
+// buildDerivativeConditions computes for a derivative CNP, mirroring
+// v1.Condition's fields. It is logged by logDerivativeConditions and is not
+// written onto the parent CNP's status.
+type DerivativeCondition struct {
+	Type               ConditionType          `json:"type"`
+	Status             v1.ConditionStatus     `json:"status"`
+	LastTransitionTime v1.Time                `json:"lastTransitionTime"`
+	Reason             string                 `json:"reason,omitempty"`
+	Message            string                 `json:"message,omitempty"`
+	// LastKnownGoodIPCount is the number of member IPs in the last
+	// resolution that succeeded, even if the current one did not.
+	LastKnownGoodIPCount int `json:"lastKnownGoodIPCount"`
+}
+
+// buildDerivativeConditions computes the Ready/Degraded/Stale conditions
+// for a derivative CNP from the outcome of its latest resolution attempt.
+//
+// NOTE: updateDerivativeStatus does not accept structured conditions yet, so
+// logDerivativeConditions (actions.go) logs the result of this function
+// rather than persisting it onto the parent CNP's status; extending
+// updateDerivativeStatus to accept and store them is follow-up work. Until
+// then, operators can read them from the log alongside groupsmetrics and the
+// plain status message updateDerivativeStatus already writes.
+func buildDerivativeConditions(err error, ipCount int, lastGoodIPCount int, lastResolved time.Time, ttl time.Duration) []DerivativeCondition {
+	now := v1.Now()
+
+	ready := v1.ConditionTrue
+	degraded := v1.ConditionFalse
+	reason := "ResolutionSucceeded"
+	message := ""
+	if err != nil {
+		ready = v1.ConditionFalse
+		degraded = v1.ConditionTrue
+		reason = "ResolutionFailed"
+		message = err.Error()
+	}
+
+	stale := v1.ConditionFalse
+	if ttl > 0 && time.Since(lastResolved) > ttl {
+		stale = v1.ConditionTrue
+	}
+
+	goodCount := ipCount
+	if err != nil {
+		goodCount = lastGoodIPCount
+	}
+
+	return []DerivativeCondition{
+		{
+			Type:                 ConditionReady,
+			Status:               ready,
+			LastTransitionTime:   now,
+			Reason:               reason,
+			Message:              message,
+			LastKnownGoodIPCount: goodCount,
+		},
+		{
+			Type:                 ConditionDegraded,
+			Status:               degraded,
+			LastTransitionTime:   now,
+			Reason:               reason,
+			Message:              message,
+			LastKnownGoodIPCount: goodCount,
+		},
+		{
+			Type:                 ConditionStale,
+			Status:               stale,
+			LastTransitionTime:   now,
+			Reason:               "ResolutionAge",
+			LastKnownGoodIPCount: goodCount,
+		},
+	}
+}
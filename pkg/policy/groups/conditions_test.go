@@ -0,0 +1,80 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package groups
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	groupstore "github.com/cilium/cilium/pkg/policy/groups/store"
+
+	"gopkg.in/check.v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type ConditionsSuite struct{}
+
+var _ = check.Suite(&ConditionsSuite{})
+
+func conditionByType(conditions []DerivativeCondition, t ConditionType) DerivativeCondition {
+	for _, cond := range conditions {
+		if cond.Type == t {
+			return cond
+		}
+	}
+	return DerivativeCondition{}
+}
+
+func (s *ConditionsSuite) TestBuildDerivativeConditionsSuccess(c *check.C) {
+	conditions := buildDerivativeConditions(nil, 3, 3, time.Now(), groupstore.DefaultTTL)
+
+	ready := conditionByType(conditions, ConditionReady)
+	c.Assert(ready.Status, check.Equals, v1.ConditionTrue)
+	c.Assert(ready.LastKnownGoodIPCount, check.Equals, 3)
+
+	degraded := conditionByType(conditions, ConditionDegraded)
+	c.Assert(degraded.Status, check.Equals, v1.ConditionFalse)
+}
+
+func (s *ConditionsSuite) TestBuildDerivativeConditionsFailureKeepsLastGoodCount(c *check.C) {
+	conditions := buildDerivativeConditions(errors.New("boom"), 0, 5, time.Now(), groupstore.DefaultTTL)
+
+	ready := conditionByType(conditions, ConditionReady)
+	c.Assert(ready.Status, check.Equals, v1.ConditionFalse)
+	c.Assert(ready.Reason, check.Equals, "ResolutionFailed")
+	c.Assert(ready.LastKnownGoodIPCount, check.Equals, 5)
+
+	degraded := conditionByType(conditions, ConditionDegraded)
+	c.Assert(degraded.Status, check.Equals, v1.ConditionTrue)
+}
+
+func (s *ConditionsSuite) TestBuildDerivativeConditionsStale(c *check.C) {
+	old := time.Now().Add(-2 * groupstore.DefaultTTL)
+	conditions := buildDerivativeConditions(nil, 1, 1, old, groupstore.DefaultTTL)
+
+	stale := conditionByType(conditions, ConditionStale)
+	c.Assert(stale.Status, check.Equals, v1.ConditionTrue)
+}
+
+func (s *ConditionsSuite) TestBuildDerivativeConditionsNotStaleWithoutTTL(c *check.C) {
+	old := time.Now().Add(-24 * time.Hour)
+	conditions := buildDerivativeConditions(nil, 1, 1, old, 0)
+
+	stale := conditionByType(conditions, ConditionStale)
+	c.Assert(stale.Status, check.Equals, v1.ConditionFalse)
+}
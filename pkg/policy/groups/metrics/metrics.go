@@ -0,0 +1,192 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics exposes Prometheus metrics for the ToGroups derivative
+// CNP lifecycle: how long provider calls take, how often the shared kvstore
+// cache is consulted successfully, how many retries each provider needed,
+// and how stale the last successful resolution of a given CNP is. These
+// give operators the visibility that the generic metrics.PolicyImportErrors
+// counter alone cannot: which provider is slow, which is being retried, and
+// whether a derivative is deny-all because of throttling or because it was
+// never able to resolve even once.
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "cilium_operator"
+
+var (
+	// ResolutionDuration is the latency of a single provider call made to
+	// resolve a ToGroups rule, labelled by provider.
+	ResolutionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "groups_resolution_duration_seconds",
+		Help:      "Duration of a ToGroups resolution call to a cloud provider",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"provider"})
+
+	// CacheHits counts lookups that found an unexpired entry in the shared
+	// kvstore cache, labelled by provider. A hit does not skip the provider
+	// call: addDerivativeCNP resolves through the provider either way.
+	CacheHits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "groups_cache_hits_total",
+		Help:      "Number of ToGroups lookups that found an unexpired entry in the shared kvstore cache",
+	}, []string{"provider"})
+
+	// CacheMisses counts lookups that found no unexpired entry in the
+	// shared kvstore cache, either because nothing was cached yet or the
+	// entry had expired, labelled by provider.
+	CacheMisses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "groups_cache_misses_total",
+		Help:      "Number of ToGroups lookups that found no unexpired entry in the shared kvstore cache",
+	}, []string{"provider"})
+
+	// RetryAttempts counts every retried resolution attempt, labelled by
+	// provider and a coarse error class so dashboards can tell throttling
+	// apart from e.g. a missing group.
+	RetryAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "groups_retry_attempts_total",
+		Help:      "Number of retried ToGroups resolution attempts",
+	}, []string{"provider", "error_class"})
+
+	// lastResolutionAge exposes, per derivative CNP, the age of the last
+	// successful resolution. It is a collector rather than a plain
+	// GaugeVec so that the age is computed from the recorded timestamp at
+	// scrape time: a Set value would freeze at whatever was last pushed and
+	// never grow between reconciliations, defeating the point of a
+	// staleness metric.
+	lastResolutionAge = newResolutionAgeCollector()
+)
+
+func init() {
+	prometheus.MustRegister(
+		ResolutionDuration,
+		CacheHits,
+		CacheMisses,
+		RetryAttempts,
+		lastResolutionAge,
+	)
+}
+
+// resolutionAgeCollector computes groups_last_resolution_age_seconds from
+// recorded last-success timestamps at scrape time, so the value keeps
+// growing between reconciliations instead of only changing when
+// RecordSuccessfulResolution is next called.
+type resolutionAgeCollector struct {
+	desc *prometheus.Desc
+
+	mutex       sync.RWMutex
+	lastSuccess map[[2]string]time.Time
+}
+
+func newResolutionAgeCollector() *resolutionAgeCollector {
+	return &resolutionAgeCollector{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "groups_last_resolution_age_seconds"),
+			"Age of the last successful ToGroups resolution for a derivative CNP",
+			[]string{"cnp_namespace", "cnp_name"},
+			nil,
+		),
+		lastSuccess: map[[2]string]time.Time{},
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *resolutionAgeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector.
+func (c *resolutionAgeCollector) Collect(ch chan<- prometheus.Metric) {
+	now := time.Now()
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	for key, lastSuccess := range c.lastSuccess {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, now.Sub(lastSuccess).Seconds(), key[0], key[1])
+	}
+}
+
+func (c *resolutionAgeCollector) recordSuccess(cnpNamespace, cnpName string, at time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.lastSuccess[[2]string{cnpNamespace, cnpName}] = at
+}
+
+func (c *resolutionAgeCollector) forget(cnpNamespace, cnpName string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.lastSuccess, [2]string{cnpNamespace, cnpName})
+}
+
+// ErrorClass buckets a resolution error for the RetryAttempts counter.
+type ErrorClass string
+
+const (
+	// ErrorClassThrottled marks a retry caused by the provider rate
+	// limiting the caller.
+	ErrorClassThrottled ErrorClass = "throttled"
+	// ErrorClassNotFound marks a retry caused by the group no longer
+	// existing at the provider.
+	ErrorClassNotFound ErrorClass = "not_found"
+	// ErrorClassOther covers every other resolution failure.
+	ErrorClassOther ErrorClass = "other"
+)
+
+// ObserveResolution records the latency of a single provider call.
+func ObserveResolution(provider string, duration time.Duration) {
+	ResolutionDuration.WithLabelValues(provider).Observe(duration.Seconds())
+}
+
+// ObserveCacheHit records that a lookup found an unexpired entry in the
+// shared cache.
+func ObserveCacheHit(provider string) {
+	CacheHits.WithLabelValues(provider).Inc()
+}
+
+// ObserveCacheMiss records that a lookup found no unexpired entry in the
+// shared cache.
+func ObserveCacheMiss(provider string) {
+	CacheMisses.WithLabelValues(provider).Inc()
+}
+
+// ObserveRetry records a retried resolution attempt for provider, bucketed
+// by errClass.
+func ObserveRetry(provider string, errClass ErrorClass) {
+	RetryAttempts.WithLabelValues(provider, string(errClass)).Inc()
+}
+
+// RecordSuccessfulResolution records that cnpNamespace/cnpName resolved
+// successfully just now; groups_last_resolution_age_seconds reports the
+// time elapsed since this call at every subsequent scrape, until the next
+// successful resolution.
+func RecordSuccessfulResolution(cnpNamespace, cnpName string) {
+	lastResolutionAge.recordSuccess(cnpNamespace, cnpName, time.Now())
+}
+
+// ForgetCNP removes cnpNamespace/cnpName's entry from
+// groups_last_resolution_age_seconds. Callers must call this when a
+// derivative CNP is deleted, or the gauge keeps reporting an ever-growing,
+// misleading age for a CNP that no longer exists.
+func ForgetCNP(cnpNamespace, cnpName string) {
+	lastResolutionAge.forget(cnpNamespace, cnpName)
+}
@@ -0,0 +1,75 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type MetricsSuite struct{}
+
+var _ = check.Suite(&MetricsSuite{})
+
+func (s *MetricsSuite) TestResolutionAgeGrowsBetweenScrapes(c *check.C) {
+	collector := newResolutionAgeCollector()
+	collector.recordSuccess("default", "cnp-1", time.Now().Add(-5*time.Second))
+
+	samples := collectSamples(collector)
+	c.Assert(len(samples), check.Equals, 1)
+	c.Assert(samples[0] >= 5, check.Equals, true)
+
+	time.Sleep(10 * time.Millisecond)
+	later := collectSamples(collector)
+	c.Assert(len(later), check.Equals, 1)
+	c.Assert(later[0] > samples[0], check.Equals, true)
+}
+
+func (s *MetricsSuite) TestForgetCNPRemovesEntry(c *check.C) {
+	collector := newResolutionAgeCollector()
+	collector.recordSuccess("default", "cnp-1", time.Now())
+	c.Assert(len(collectSamples(collector)), check.Equals, 1)
+
+	collector.forget("default", "cnp-1")
+	c.Assert(len(collectSamples(collector)), check.Equals, 0)
+}
+
+func (s *MetricsSuite) TestForgetCNPIsNoOpWhenUnknown(c *check.C) {
+	collector := newResolutionAgeCollector()
+	collector.forget("default", "cnp-unknown")
+	c.Assert(len(collectSamples(collector)), check.Equals, 0)
+}
+
+func collectSamples(collector *resolutionAgeCollector) []float64 {
+	ch := make(chan prometheus.Metric, 8)
+	collector.Collect(ch)
+	close(ch)
+
+	var values []float64
+	for metric := range ch {
+		var dtoMetric dto.Metric
+		if err := metric.Write(&dtoMetric); err != nil {
+			continue
+		}
+		values = append(values, dtoMetric.GetGauge().GetValue())
+	}
+	return values
+}
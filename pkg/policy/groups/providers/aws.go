@@ -0,0 +1,103 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// awsSelectorKey is the Selector field carrying the AWS security group IDs
+// that a ToGroups rule resolves to, matching the securityGroupsIds field on
+// cilium_v2.ToGroups.
+const awsSelectorKey = "securityGroupsIds"
+
+// awsProvider resolves ToGroups rules against EC2 security groups: every
+// running instance with a matching security group contributes its private
+// IP to the derivative CNP.
+type awsProvider struct {
+	newClient func() (*ec2.EC2, error)
+}
+
+func init() {
+	Register(&awsProvider{newClient: newEC2Client})
+}
+
+func newEC2Client() (*ec2.EC2, error) {
+	sess, err := session.NewSessionWithOptions(session.Options{SharedConfigState: session.SharedConfigEnable})
+	if err != nil {
+		return nil, err
+	}
+	return ec2.New(sess), nil
+}
+
+// Kind implements GroupProvider.
+func (a *awsProvider) Kind() string {
+	return KindAWS
+}
+
+// Validate implements GroupProvider.
+func (a *awsProvider) Validate(spec GroupSpec) error {
+	if spec.Selector[awsSelectorKey] == "" {
+		return fmt.Errorf("aws ToGroups rule is missing %q", awsSelectorKey)
+	}
+	return nil
+}
+
+// Resolve implements GroupProvider.
+func (a *awsProvider) Resolve(ctx context.Context, spec GroupSpec) ([]net.IP, error) {
+	if err := a.Validate(spec); err != nil {
+		return nil, err
+	}
+
+	client, err := a.newClient()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := client.DescribeInstancesWithContext(ctx, &ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("instance.group-id"),
+				Values: []*string{aws.String(spec.Selector[awsSelectorKey])},
+			},
+			{
+				Name:   aws.String("instance-state-name"),
+				Values: []*string{aws.String("running")},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []net.IP
+	for _, reservation := range out.Reservations {
+		for _, instance := range reservation.Instances {
+			if instance.PrivateIpAddress == nil {
+				continue
+			}
+			if ip := net.ParseIP(*instance.PrivateIpAddress); ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return ips, nil
+}
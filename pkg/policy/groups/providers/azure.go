@@ -0,0 +1,194 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2018-06-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2018-08-01/network"
+	"github.com/Azure/go-autorest/autorest/azure/auth"
+)
+
+// Azure ToGroups rules identify a group by resource-group+VMSS name.
+const (
+	azureSelectorResourceGroup = "resourceGroup"
+	azureSelectorScaleSet      = "scaleSet"
+)
+
+// azureProvider resolves ToGroups rules against the instances in a virtual
+// machine scale set (VMSS).
+type azureProvider struct {
+	newClient        func() (compute.VirtualMachineScaleSetVMsClient, error)
+	newNetworkClient func() (network.InterfacesClient, error)
+}
+
+func init() {
+	Register(&azureProvider{
+		newClient:        newScaleSetVMsClient,
+		newNetworkClient: newInterfacesClient,
+	})
+}
+
+func newScaleSetVMsClient() (compute.VirtualMachineScaleSetVMsClient, error) {
+	authorizer, err := auth.NewAuthorizerFromEnvironment()
+	if err != nil {
+		return compute.VirtualMachineScaleSetVMsClient{}, err
+	}
+	client := compute.NewVirtualMachineScaleSetVMsClient(os.Getenv("AZURE_SUBSCRIPTION_ID"))
+	client.Authorizer = authorizer
+	return client, nil
+}
+
+func newInterfacesClient() (network.InterfacesClient, error) {
+	authorizer, err := auth.NewAuthorizerFromEnvironment()
+	if err != nil {
+		return network.InterfacesClient{}, err
+	}
+	client := network.NewInterfacesClient(os.Getenv("AZURE_SUBSCRIPTION_ID"))
+	client.Authorizer = authorizer
+	return client, nil
+}
+
+// Kind implements GroupProvider.
+func (p *azureProvider) Kind() string {
+	return KindAzure
+}
+
+// Validate implements GroupProvider.
+func (p *azureProvider) Validate(spec GroupSpec) error {
+	if spec.Selector[azureSelectorResourceGroup] == "" || spec.Selector[azureSelectorScaleSet] == "" {
+		return fmt.Errorf("azure ToGroups rule is missing %q or %q", azureSelectorResourceGroup, azureSelectorScaleSet)
+	}
+	return nil
+}
+
+// Resolve implements GroupProvider.
+func (p *azureProvider) Resolve(ctx context.Context, spec GroupSpec) ([]net.IP, error) {
+	if err := p.Validate(spec); err != nil {
+		return nil, err
+	}
+
+	client, err := p.newClient()
+	if err != nil {
+		return nil, err
+	}
+
+	resourceGroup := spec.Selector[azureSelectorResourceGroup]
+	scaleSet := spec.Selector[azureSelectorScaleSet]
+
+	page, err := client.ListComplete(ctx, resourceGroup, scaleSet, "", "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	var ifaceIDs []string
+	for page.NotDone() {
+		vm := page.Value()
+		if profile := vm.NetworkProfile; profile != nil {
+			for _, iface := range *profile.NetworkInterfaces {
+				if iface.ID != nil {
+					ifaceIDs = append(ifaceIDs, *iface.ID)
+				}
+			}
+		}
+		if err := page.NextWithContext(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return p.privateIPsForInterfaces(ctx, ifaceIDs)
+}
+
+// privateIPsForInterfaces resolves each scale-set VM network interface ID
+// to its private IP address via the network client.
+func (p *azureProvider) privateIPsForInterfaces(ctx context.Context, ifaceIDs []string) ([]net.IP, error) {
+	client, err := p.newNetworkClient()
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []net.IP
+	for _, id := range ifaceIDs {
+		resourceGroup, scaleSet, vmIndex, ifaceName, err := parseScaleSetInterfaceID(id)
+		if err != nil {
+			log.WithError(err).WithField("interfaceID", id).Warning("Skipping unparsable VMSS network interface ID")
+			continue
+		}
+
+		iface, err := client.GetVirtualMachineScaleSetNetworkInterface(ctx, resourceGroup, scaleSet, vmIndex, ifaceName, "")
+		if err != nil {
+			return nil, err
+		}
+		if iface.IPConfigurations == nil {
+			continue
+		}
+		for _, ipConfig := range *iface.IPConfigurations {
+			if ipConfig.PrivateIPAddress == nil {
+				continue
+			}
+			if ip := net.ParseIP(*ipConfig.PrivateIPAddress); ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return ips, nil
+}
+
+// parseScaleSetInterfaceID extracts the resource group, scale set name, VM
+// index and interface name from a VMSS network interface resource ID of the
+// form:
+// .../resourceGroups/<rg>/.../virtualMachineScaleSets/<vmss>/virtualMachines/<idx>/networkInterfaces/<name>
+func parseScaleSetInterfaceID(id string) (resourceGroup, scaleSet, vmIndex, ifaceName string, err error) {
+	segments := splitPath(id)
+	get := func(marker string) (string, bool) {
+		for i, s := range segments {
+			if s == marker && i+1 < len(segments) {
+				return segments[i+1], true
+			}
+		}
+		return "", false
+	}
+
+	var ok1, ok2, ok3, ok4 bool
+	resourceGroup, ok1 = get("resourceGroups")
+	scaleSet, ok2 = get("virtualMachineScaleSets")
+	vmIndex, ok3 = get("virtualMachines")
+	ifaceName, ok4 = get("networkInterfaces")
+	if !ok1 || !ok2 || !ok3 || !ok4 {
+		err = fmt.Errorf("malformed VMSS network interface ID %q", id)
+	}
+	return
+}
+
+func splitPath(id string) []string {
+	var segments []string
+	start := 0
+	for i := 0; i < len(id); i++ {
+		if id[i] == '/' {
+			if i > start {
+				segments = append(segments, id[start:i])
+			}
+			start = i + 1
+		}
+	}
+	if start < len(id) {
+		segments = append(segments, id[start:])
+	}
+	return segments
+}
@@ -0,0 +1,151 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	compute "google.golang.org/api/compute/v1"
+)
+
+// GCP ToGroups rules identify a group by project+zone+instance-group-name,
+// or by network tag when no instance group is given.
+const (
+	gcpSelectorProject       = "project"
+	gcpSelectorZone          = "zone"
+	gcpSelectorInstanceGroup = "instanceGroup"
+	gcpSelectorNetworkTag    = "networkTag"
+)
+
+// gcpProvider resolves ToGroups rules against either a managed/unmanaged
+// GCE instance group or a network tag shared by a set of instances.
+type gcpProvider struct {
+	newService func(ctx context.Context) (*compute.Service, error)
+}
+
+func init() {
+	Register(&gcpProvider{newService: compute.NewService})
+}
+
+// Kind implements GroupProvider.
+func (g *gcpProvider) Kind() string {
+	return KindGCP
+}
+
+// Validate implements GroupProvider.
+func (g *gcpProvider) Validate(spec GroupSpec) error {
+	if spec.Selector[gcpSelectorProject] == "" || spec.Selector[gcpSelectorZone] == "" {
+		return fmt.Errorf("gcp ToGroups rule is missing %q or %q", gcpSelectorProject, gcpSelectorZone)
+	}
+	if spec.Selector[gcpSelectorInstanceGroup] == "" && spec.Selector[gcpSelectorNetworkTag] == "" {
+		return fmt.Errorf("gcp ToGroups rule must set either %q or %q", gcpSelectorInstanceGroup, gcpSelectorNetworkTag)
+	}
+	return nil
+}
+
+// Resolve implements GroupProvider.
+func (g *gcpProvider) Resolve(ctx context.Context, spec GroupSpec) ([]net.IP, error) {
+	if err := g.Validate(spec); err != nil {
+		return nil, err
+	}
+
+	svc, err := g.newService(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	project := spec.Selector[gcpSelectorProject]
+	zone := spec.Selector[gcpSelectorZone]
+
+	if group := spec.Selector[gcpSelectorInstanceGroup]; group != "" {
+		return g.resolveInstanceGroup(svc, project, zone, group)
+	}
+	return g.resolveNetworkTag(svc, project, zone, spec.Selector[gcpSelectorNetworkTag])
+}
+
+func (g *gcpProvider) resolveInstanceGroup(svc *compute.Service, project, zone, group string) ([]net.IP, error) {
+	resp, err := svc.InstanceGroups.ListInstances(project, zone, group, &compute.InstanceGroupsListInstancesRequest{
+		InstanceState: "RUNNING",
+	}).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []net.IP
+	for _, item := range resp.Items {
+		instance, err := instanceFromURL(svc, project, zone, item.Instance)
+		if err != nil {
+			return nil, err
+		}
+		ips = append(ips, instancePrivateIPs(instance)...)
+	}
+	return ips, nil
+}
+
+func (g *gcpProvider) resolveNetworkTag(svc *compute.Service, project, zone, tag string) ([]net.IP, error) {
+	resp, err := svc.Instances.List(project, zone).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []net.IP
+	for _, instance := range resp.Items {
+		if !hasTag(instance, tag) {
+			continue
+		}
+		ips = append(ips, instancePrivateIPs(instance)...)
+	}
+	return ips, nil
+}
+
+func instanceFromURL(svc *compute.Service, project, zone, url string) (*compute.Instance, error) {
+	return svc.Instances.Get(project, zone, instanceNameFromURL(url)).Do()
+}
+
+func hasTag(instance *compute.Instance, tag string) bool {
+	if instance.Tags == nil {
+		return false
+	}
+	for _, t := range instance.Tags.Items {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func instancePrivateIPs(instance *compute.Instance) []net.IP {
+	var ips []net.IP
+	for _, iface := range instance.NetworkInterfaces {
+		if ip := net.ParseIP(iface.NetworkIP); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+// instanceNameFromURL extracts the trailing instance name from a fully
+// qualified GCE resource URL, e.g.
+// ".../zones/us-central1-a/instances/foo" -> "foo".
+func instanceNameFromURL(url string) string {
+	for i := len(url) - 1; i >= 0; i-- {
+		if url[i] == '/' {
+			return url[i+1:]
+		}
+	}
+	return url
+}
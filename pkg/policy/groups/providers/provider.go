@@ -0,0 +1,80 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"context"
+	"net"
+)
+
+// Well-known provider kinds, matching the GroupProvider.Kind() values
+// registered by this package's own AWS, GCP and Azure implementations.
+const (
+	KindAWS   = "aws"
+	KindGCP   = "gcp"
+	KindAzure = "azure"
+)
+
+// GroupSpec is the provider-agnostic description of a ToGroups selector.
+// Kind selects which registered GroupProvider handles the rule; Selector
+// carries whatever key/value pairs that provider needs to identify the
+// group (e.g. security-group-id, or the instance group's project+zone+name
+// for GCP). It is kept generic here so the registry does not need to import
+// the API types package. cilium_v2.ToGroups has no provider-tagged fields
+// that map onto it; see the NOTE on GroupProvider.
+type GroupSpec struct {
+	// Kind is the ToGroups selector kind, e.g. "aws", "gcp" or "azure".
+	Kind string
+	// Selector holds the provider-specific identifying fields for the
+	// group, e.g. {"securityGroupsIds": "sg-xxxx"} for AWS.
+	Selector map[string]string
+}
+
+// GroupProvider resolves a ToGroups selector into a concrete set of member
+// IPs. Implementations are registered by Kind() and looked up through the
+// registry in this package.
+//
+// NOTE: addDerivativeCNP (pkg/policy/groups/actions.go) only uses the
+// registry to check that a provider is registered for a CNP's ToGroups
+// kind before attempting resolution; createDerivativeCNP itself still
+// resolves AWS security groups directly rather than going through
+// GroupProvider.Resolve. GCP and Azure are therefore registered but not
+// reachable from a real CNP yet. Routing createDerivativeCNP's resolution
+// through this registry is blocked on two changes outside this package's
+// current source tree, not on anything in this package: cilium_v2.ToGroups
+// needs a provider-tagged selector per rule (the fields GroupSpec.Selector
+// expects, e.g. securityGroupsIds for AWS) instead of assuming every rule
+// is AWS, and createDerivativeCNP needs to build a GroupSpec from that
+// selector and call Resolve instead of resolving AWS inline. Neither
+// cilium_v2.ToGroups' field set nor createDerivativeCNP's body is declared
+// anywhere in this tree, so this package cannot make that change itself
+// without guessing at both.
+type GroupProvider interface {
+	// Kind returns the ToGroups selector kind this provider handles, used
+	// as the registry key. It must be stable and unique across providers.
+	Kind() string
+
+	// Validate checks that spec carries everything this provider needs
+	// before a Resolve is attempted. The AWS, GCP and Azure providers call
+	// it at the start of their own Resolve; nothing calls it at CNP
+	// admission time.
+	Validate(spec GroupSpec) error
+
+	// Resolve returns the current member IPs for the group described by
+	// spec. Transient provider throttling must be returned as an error
+	// that ratelimit.IsThrottlingError recognizes for that provider, so the
+	// shared limiter can back off on behalf of every CNP using it.
+	Resolve(ctx context.Context, spec GroupSpec) ([]net.IP, error)
+}
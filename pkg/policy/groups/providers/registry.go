@@ -0,0 +1,52 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"fmt"
+	"sync"
+)
+
+var (
+	registryMutex sync.RWMutex
+	registry      = map[string]GroupProvider{}
+)
+
+// Register adds p to the registry, keyed by p.Kind(). It panics if a
+// provider for the same kind is already registered, the same way
+// controllers and other global registries in cilium fail fast on a
+// programming error rather than silently shadowing the earlier
+// registration. Register is meant to be called from package init
+// functions, including from out-of-tree packages that want to add a
+// GroupProvider of their own.
+func Register(p GroupProvider) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	kind := p.Kind()
+	if _, exists := registry[kind]; exists {
+		panic(fmt.Sprintf("GroupProvider for kind %q already registered", kind))
+	}
+	registry[kind] = p
+	log.WithField("kind", kind).Debug("Registered ToGroups provider")
+}
+
+// Lookup returns the GroupProvider registered for kind, if any.
+func Lookup(kind string) (GroupProvider, bool) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	p, ok := registry[kind]
+	return p, ok
+}
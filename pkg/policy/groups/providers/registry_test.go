@@ -0,0 +1,64 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type ProvidersSuite struct{}
+
+var _ = check.Suite(&ProvidersSuite{})
+
+func (s *ProvidersSuite) TestLookupKnownProviders(c *check.C) {
+	for _, kind := range []string{KindAWS, KindGCP, KindAzure} {
+		p, ok := Lookup(kind)
+		c.Assert(ok, check.Equals, true)
+		c.Assert(p.Kind(), check.Equals, kind)
+	}
+}
+
+func (s *ProvidersSuite) TestLookupUnknownKind(c *check.C) {
+	_, ok := Lookup("digitalocean")
+	c.Assert(ok, check.Equals, false)
+}
+
+type fakeProvider struct{ kind string }
+
+func (f *fakeProvider) Kind() string                 { return f.kind }
+func (f *fakeProvider) Validate(spec GroupSpec) error { return nil }
+func (f *fakeProvider) Resolve(ctx context.Context, spec GroupSpec) ([]net.IP, error) {
+	return nil, nil
+}
+
+func (s *ProvidersSuite) TestRegisterPanicsOnDuplicateKind(c *check.C) {
+	const kind = "fake-for-test"
+	Register(&fakeProvider{kind: kind})
+
+	c.Assert(func() { Register(&fakeProvider{kind: kind}) }, check.PanicMatches,
+		`GroupProvider for kind "fake-for-test" already registered`)
+}
+
+func (s *ProvidersSuite) TestAWSValidateRequiresSecurityGroupIds(c *check.C) {
+	p := &awsProvider{}
+	c.Assert(p.Validate(GroupSpec{}), check.NotNil)
+	c.Assert(p.Validate(GroupSpec{Selector: map[string]string{awsSelectorKey: "sg-1234"}}), check.IsNil)
+}
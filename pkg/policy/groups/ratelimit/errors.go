@@ -0,0 +1,61 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+// awsThrottleCodes are the awserr.Error codes that the EC2 and IAM APIs
+// return when a caller is being rate limited.
+var awsThrottleCodes = map[string]struct{}{
+	"RequestLimitExceeded":     {},
+	"Throttling":               {},
+	"ThrottlingException":      {},
+	"TooManyRequestsException": {},
+}
+
+// IsThrottlingError returns true if err was returned by a cloud provider SDK
+// to indicate that the caller is being rate limited, as opposed to any other
+// kind of failure (e.g. a group that no longer exists). Only throttling
+// errors should be fed back into the Manager via ReportThrottled; any other
+// error should be surfaced to the derivative CNP status unchanged.
+func IsThrottlingError(provider Provider, err error) bool {
+	if err == nil {
+		return false
+	}
+
+	switch provider {
+	case AWS:
+		if awsErr, ok := err.(awserr.Error); ok {
+			_, throttled := awsThrottleCodes[awsErr.Code()]
+			return throttled
+		}
+	case GCP:
+		// The GCP compute API returns a googleapi.Error whose Code is 429,
+		// but it is not worth vendoring the client solely to type-assert
+		// here; the message is stable across API versions.
+		return strings.Contains(err.Error(), "rateLimitExceeded") ||
+			strings.Contains(err.Error(), "userRateLimitExceeded")
+	case Azure:
+		// The Azure SDK surfaces throttling as a 429 with this substring in
+		// the autorest DetailedError message.
+		return strings.Contains(err.Error(), "TooManyRequests")
+	}
+
+	return false
+}
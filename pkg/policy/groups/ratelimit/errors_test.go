@@ -0,0 +1,46 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"gopkg.in/check.v1"
+)
+
+func (s *RateLimitSuite) TestIsThrottlingErrorNil(c *check.C) {
+	c.Assert(IsThrottlingError(AWS, nil), check.Equals, false)
+}
+
+func (s *RateLimitSuite) TestIsThrottlingErrorAWS(c *check.C) {
+	throttled := awserr.New("RequestLimitExceeded", "too many requests", nil)
+	c.Assert(IsThrottlingError(AWS, throttled), check.Equals, true)
+
+	notFound := awserr.New("InvalidGroup.NotFound", "no such group", nil)
+	c.Assert(IsThrottlingError(AWS, notFound), check.Equals, false)
+
+	c.Assert(IsThrottlingError(AWS, errors.New("plain error")), check.Equals, false)
+}
+
+func (s *RateLimitSuite) TestIsThrottlingErrorGCP(c *check.C) {
+	c.Assert(IsThrottlingError(GCP, errors.New("googleapi: Error 429: userRateLimitExceeded")), check.Equals, true)
+	c.Assert(IsThrottlingError(GCP, errors.New("googleapi: Error 404: not found")), check.Equals, false)
+}
+
+func (s *RateLimitSuite) TestIsThrottlingErrorAzure(c *check.C) {
+	c.Assert(IsThrottlingError(Azure, errors.New("autorest: StatusCode=429 TooManyRequests")), check.Equals, true)
+	c.Assert(IsThrottlingError(Azure, errors.New("autorest: StatusCode=404")), check.Equals, false)
+}
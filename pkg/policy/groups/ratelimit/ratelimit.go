@@ -0,0 +1,186 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+// Provider identifies the cloud provider that a ToGroups rule resolves
+// against. Each provider gets its own token bucket and backoff state so
+// that throttling on one provider never affects the others.
+type Provider string
+
+const (
+	// AWS is the provider identifier for Amazon Web Services.
+	AWS Provider = "aws"
+	// GCP is the provider identifier for Google Cloud Platform.
+	GCP Provider = "gcp"
+	// Azure is the provider identifier for Microsoft Azure.
+	Azure Provider = "azure"
+)
+
+const (
+	// defaultRatePerSecond is the steady-state number of calls allowed per
+	// second against a single provider, chosen to stay well under the
+	// default API rate limits of AWS, GCP and Azure.
+	defaultRatePerSecond = 10
+	// defaultBurst allows a small burst above the steady-state rate so that
+	// the first handful of CNPs on an idle cluster do not have to wait.
+	defaultBurst = 5
+
+	// minBackoff is the upper bound of the jittered delay drawn after the
+	// first reported throttling event; each further consecutive event
+	// doubles that bound, up to maxBackoff.
+	minBackoff = 1 * time.Second
+	// maxBackoff caps the exponential backoff so a persistently throttled
+	// provider does not push reconciliation out for an unbounded amount of
+	// time.
+	maxBackoff = 2 * time.Minute
+)
+
+// providerState tracks the token bucket and backoff state for a single
+// provider.
+type providerState struct {
+	limiter *rate.Limiter
+
+	mutex        sync.Mutex
+	attempt      int
+	lastThrottle time.Time
+}
+
+// Manager hands out tokens for cloud provider calls on a per-provider basis
+// and tracks exponential backoff driven by throttling feedback from the
+// providers themselves. A single Manager is shared by every derivative CNP
+// so that, for example, every policy resolving against AWS security groups
+// backs off together when AWS starts throttling the account.
+type Manager struct {
+	mutex     sync.Mutex
+	providers map[Provider]*providerState
+}
+
+// NewManager returns a Manager with the default token bucket configuration.
+func NewManager() *Manager {
+	return &Manager{
+		providers: map[Provider]*providerState{},
+	}
+}
+
+func (m *Manager) state(provider Provider) *providerState {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	state, ok := m.providers[provider]
+	if !ok {
+		state = &providerState{
+			limiter: rate.NewLimiter(rate.Limit(defaultRatePerSecond), defaultBurst),
+		}
+		m.providers[provider] = state
+	}
+	return state
+}
+
+// Acquire blocks until a token for the given provider is available, the
+// provider's current backoff window has elapsed, or ctx is cancelled.
+//
+// Acquire gates one logical resolution attempt, not one provider API call:
+// addDerivativeCNP calls it once per retry-loop iteration, before
+// createDerivativeCNP runs. If createDerivativeCNP ever issues more than one
+// provider call per invocation (e.g. a CNP with multiple ToGroups rules),
+// those inner calls are not individually rate limited; this is a known,
+// coarser-grained approximation until createDerivativeCNP is changed to
+// acquire a token per provider call itself.
+func (m *Manager) Acquire(ctx context.Context, provider Provider) error {
+	state := m.state(provider)
+
+	state.mutex.Lock()
+	backoff := state.backoffLocked()
+	state.mutex.Unlock()
+
+	if backoff > 0 {
+		log.WithFields(logrus.Fields{
+			"provider": provider,
+			"backoff":  backoff,
+		}).Debug("Waiting for provider backoff before acquiring rate limit token")
+		timer := time.NewTimer(backoff)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return state.limiter.Wait(ctx)
+}
+
+// ReportThrottled must be called whenever a provider call fails with a
+// throttling error. It increases the backoff applied to subsequent Acquire
+// calls for that provider, independently of the token bucket rate.
+func (m *Manager) ReportThrottled(provider Provider) {
+	state := m.state(provider)
+	state.mutex.Lock()
+	state.attempt++
+	attempt := state.attempt
+	state.lastThrottle = time.Now()
+	state.mutex.Unlock()
+
+	log.WithFields(logrus.Fields{
+		"provider": provider,
+		"attempt":  attempt,
+		"backoff":  backoffDuration(attempt),
+	}).Warning("Provider call throttled, backing off")
+}
+
+// ReportSuccess must be called after a successful provider call. It resets
+// the exponential backoff for that provider so that a single transient
+// failure does not keep slowing down calls indefinitely.
+func (m *Manager) ReportSuccess(provider Provider) {
+	state := m.state(provider)
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	state.attempt = 0
+}
+
+// backoffLocked returns how long the caller should wait before the next
+// provider call, given the number of consecutive throttling events reported
+// so far. Must be called with state.mutex held.
+func (state *providerState) backoffLocked() time.Duration {
+	if state.attempt == 0 {
+		return 0
+	}
+
+	elapsed := time.Since(state.lastThrottle)
+	wait := backoffDuration(state.attempt)
+	if elapsed >= wait {
+		return 0
+	}
+	return wait - elapsed
+}
+
+// backoffDuration computes an exponential backoff with full jitter for the
+// given attempt number (1-indexed), capped at maxBackoff.
+func backoffDuration(attempt int) time.Duration {
+	exp := minBackoff * time.Duration(1<<uint(attempt-1))
+	if exp > maxBackoff || exp <= 0 {
+		exp = maxBackoff
+	}
+	return time.Duration(rand.Int63n(int64(exp)))
+}
@@ -0,0 +1,104 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type RateLimitSuite struct{}
+
+var _ = check.Suite(&RateLimitSuite{})
+
+func (s *RateLimitSuite) TestBackoffDurationCapped(c *check.C) {
+	for attempt := 1; attempt < 20; attempt++ {
+		d := backoffDuration(attempt)
+		c.Assert(d >= 0, check.Equals, true)
+		c.Assert(d <= maxBackoff, check.Equals, true)
+	}
+}
+
+func (s *RateLimitSuite) TestBackoffDurationSaturatesAtMaxBackoff(c *check.C) {
+	// minBackoff*2^(attempt-1) blows past maxBackoff well before attempt 20,
+	// at which point backoffDuration must clamp its draw ceiling to
+	// maxBackoff rather than overflowing or returning something unbounded.
+	d := backoffDuration(20)
+	c.Assert(d <= maxBackoff, check.Equals, true)
+}
+
+func (s *RateLimitSuite) TestReportSuccessResetsBackoff(c *check.C) {
+	m := NewManager()
+	m.ReportThrottled(AWS)
+
+	state := m.state(AWS)
+	state.mutex.Lock()
+	attempt := state.attempt
+	state.mutex.Unlock()
+	c.Assert(attempt, check.Equals, 1)
+
+	m.ReportSuccess(AWS)
+	state.mutex.Lock()
+	attempt = state.attempt
+	state.mutex.Unlock()
+	c.Assert(attempt, check.Equals, 0)
+}
+
+func (s *RateLimitSuite) TestReportThrottledIsPerProvider(c *check.C) {
+	m := NewManager()
+	m.ReportThrottled(AWS)
+
+	awsState := m.state(AWS)
+	gcpState := m.state(GCP)
+
+	awsState.mutex.Lock()
+	awsAttempt := awsState.attempt
+	awsState.mutex.Unlock()
+
+	gcpState.mutex.Lock()
+	gcpAttempt := gcpState.attempt
+	gcpState.mutex.Unlock()
+
+	c.Assert(awsAttempt, check.Equals, 1)
+	c.Assert(gcpAttempt, check.Equals, 0)
+}
+
+func (s *RateLimitSuite) TestAcquireReturnsOnContextCancel(c *check.C) {
+	m := NewManager()
+	m.ReportThrottled(AWS)
+	state := m.state(AWS)
+	state.mutex.Lock()
+	state.attempt = 10
+	state.lastThrottle = time.Now()
+	state.mutex.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := m.Acquire(ctx, AWS)
+	c.Assert(err, check.Equals, context.Canceled)
+}
+
+func (s *RateLimitSuite) TestAcquireSucceedsWithoutBackoff(c *check.C) {
+	m := NewManager()
+	ctx := context.Background()
+	err := m.Acquire(ctx, AWS)
+	c.Assert(err, check.IsNil)
+}
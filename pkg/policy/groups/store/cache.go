@@ -0,0 +1,157 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/cilium/cilium/pkg/kvstore"
+	kvstorestore "github.com/cilium/cilium/pkg/kvstore/store"
+)
+
+// DefaultTTL is the lifetime applied to a resolved group membership when the
+// caller does not specify one explicitly.
+const DefaultTTL = 5 * time.Minute
+
+// Cache is a kvstore-backed, cluster-wide cache of resolved ToGroups
+// memberships. The elected cilium-operator leader is the only writer;
+// followers only watch. Every operator, leader or follower, can read the
+// cache through Get.
+type Cache struct {
+	mutex   sync.RWMutex
+	entries map[string]*GroupMembers
+
+	shared   *kvstorestore.SharedStore
+	isLeader func() bool
+}
+
+// NewCache creates a Cache and joins the kvstore shared store under
+// groupMembersPrefix. sharedStoreName only labels the join in the log: every
+// Cache uses the same kvstore prefix, whatever name it is given. isLeader is
+// consulted on every Publish
+// call so that only the elected operator writes to the kvstore; it is safe
+// to pass a function whose answer changes over the lifetime of the Cache as
+// leadership changes hands.
+func NewCache(ctx context.Context, sharedStoreName string, isLeader func() bool) (*Cache, error) {
+	cache := &Cache{
+		entries:  map[string]*GroupMembers{},
+		isLeader: isLeader,
+	}
+
+	shared, err := kvstorestore.JoinSharedStore(kvstorestore.Configuration{
+		Prefix:     groupMembersPrefix,
+		KeyCreator: func() kvstorestore.Key { return newKey() },
+		Backend:    kvstore.Client(),
+		Observer:   cache,
+	})
+	if err != nil {
+		return nil, err
+	}
+	cache.shared = shared
+
+	log.WithField("sharedStore", sharedStoreName).Info("Joined shared group membership store")
+	return cache, nil
+}
+
+// Get returns the cached membership for provider+groupID, if it exists and
+// has not expired. Callers must fall back to a direct provider lookup on a
+// miss, whether because nothing has been published yet or because the
+// cached entry is stale.
+func (c *Cache) Get(provider, groupID string) (*GroupMembers, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	entry, ok := c.entries[keyName(provider, groupID)]
+	if !ok || entry.Expired(time.Now()) {
+		return nil, false
+	}
+	return entry, true
+}
+
+// Publish stores ips as the freshly resolved membership for provider+groupID;
+// it does not contact the provider itself. It is
+// a no-op, returning nil, when the local operator is not the elected leader
+// -- followers rely entirely on OnUpdate to populate their view of the
+// cache.
+func (c *Cache) Publish(ctx context.Context, provider, groupID string, ips []net.IP, ttl time.Duration) error {
+	if c.isLeader != nil && !c.isLeader() {
+		return nil
+	}
+
+	c.mutex.RLock()
+	existing, ok := c.entries[keyName(provider, groupID)]
+	c.mutex.RUnlock()
+
+	version := uint64(1)
+	if ok {
+		version = existing.Version + 1
+	}
+
+	entry := &GroupMembers{
+		Provider:   provider,
+		GroupID:    groupID,
+		IPs:        ipsToStrings(ips),
+		Version:    version,
+		ResolvedAt: time.Now(),
+		TTL:        ttl,
+	}
+
+	if err := c.shared.UpdateLocalKeySync(ctx, entry); err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	c.entries[entry.GetKeyName()] = entry
+	c.mutex.Unlock()
+	return nil
+}
+
+// Close leaves the shared store, stopping the background watch.
+func (c *Cache) Close(ctx context.Context) {
+	if c.shared != nil {
+		c.shared.Release()
+	}
+}
+
+// OnUpdate implements kvstorestore.Observer. It is invoked whenever any
+// operator, leader or follower, publishes or refreshes a group membership.
+func (c *Cache) OnUpdate(key kvstorestore.Key) {
+	entry, ok := key.(*GroupMembers)
+	if !ok {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries[entry.GetKeyName()] = entry
+}
+
+// OnDelete implements kvstorestore.Observer. It is invoked when a membership
+// is removed from the kvstore, e.g. because its TTL lease expired.
+func (c *Cache) OnDelete(key kvstorestore.NamedKey) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.entries, key.GetKeyName())
+}
+
+func ipsToStrings(ips []net.IP) []string {
+	out := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		out = append(out, ip.String())
+	}
+	return out
+}
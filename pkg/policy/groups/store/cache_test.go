@@ -0,0 +1,77 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+// newTestCache builds a Cache with no kvstore backend attached, relying only
+// on the in-memory entries map that Get/OnUpdate/OnDelete operate on, since
+// exercising the real JoinSharedStore path requires a running kvstore.
+func newTestCache() *Cache {
+	return &Cache{entries: map[string]*GroupMembers{}}
+}
+
+func (s *StoreSuite) TestCacheGetMissesUntilPublished(c *check.C) {
+	cache := newTestCache()
+	_, ok := cache.Get("aws", "sg-1234")
+	c.Assert(ok, check.Equals, false)
+
+	cache.OnUpdate(&GroupMembers{Provider: "aws", GroupID: "sg-1234", TTL: DefaultTTL, ResolvedAt: time.Now()})
+
+	entry, ok := cache.Get("aws", "sg-1234")
+	c.Assert(ok, check.Equals, true)
+	c.Assert(entry.GroupID, check.Equals, "sg-1234")
+}
+
+func (s *StoreSuite) TestCacheGetMissesExpiredEntry(c *check.C) {
+	cache := newTestCache()
+	cache.OnUpdate(&GroupMembers{
+		Provider:   "aws",
+		GroupID:    "sg-1234",
+		TTL:        DefaultTTL,
+		ResolvedAt: time.Now().Add(-2 * DefaultTTL),
+	})
+
+	_, ok := cache.Get("aws", "sg-1234")
+	c.Assert(ok, check.Equals, false)
+}
+
+func (s *StoreSuite) TestCacheOnDeleteRemovesEntry(c *check.C) {
+	cache := newTestCache()
+	entry := &GroupMembers{Provider: "aws", GroupID: "sg-1234", ResolvedAt: time.Now()}
+	cache.OnUpdate(entry)
+
+	_, ok := cache.Get("aws", "sg-1234")
+	c.Assert(ok, check.Equals, true)
+
+	cache.OnDelete(entry)
+	_, ok = cache.Get("aws", "sg-1234")
+	c.Assert(ok, check.Equals, false)
+}
+
+func (s *StoreSuite) TestCachePublishIsNoOpWhenNotLeader(c *check.C) {
+	cache := newTestCache()
+	cache.isLeader = func() bool { return false }
+
+	err := cache.Publish(nil, "aws", "sg-1234", nil, DefaultTTL)
+	c.Assert(err, check.IsNil)
+
+	_, ok := cache.Get("aws", "sg-1234")
+	c.Assert(ok, check.Equals, false)
+}
@@ -0,0 +1,32 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package store publishes resolved ToGroups memberships (provider +
+// group-identifier -> IPs) into the kvstore, so that a cluster with several
+// cilium-operator replicas (or a single operator restarting repeatedly)
+// resolves each group against the cloud provider once rather than once per
+// policy per operator lifetime. Only the elected operator leader writes to
+// the store; every operator, leader or not, watches it so that derivative
+// CNPs can be regenerated from the shared cache alone. Callers must fall
+// back to a direct provider lookup when the kvstore is unavailable, the
+// same way the rest of cilium-operator degrades when kvstore connectivity
+// is lost.
+package store
+
+import (
+	"github.com/cilium/cilium/pkg/logging"
+	"github.com/cilium/cilium/pkg/logging/logfields"
+)
+
+var log = logging.DefaultLogger.WithField(logfields.LogSubsys, "policy-groups-store")
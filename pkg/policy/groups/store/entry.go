@@ -0,0 +1,91 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// groupMembersPrefix is the kvstore prefix under which resolved groups'
+// members are published. keyName appends the provider and group identifier
+// to it so that an AWS security group and a GCP instance group with the same
+// name never collide.
+const groupMembersPrefix = "state/policygroups/v1"
+
+// GroupMembers is the value published into the kvstore for a single
+// resolved ToGroups selector. It is re-published by the leader every time
+// the membership is refreshed, and read back by every operator (leader and
+// followers alike) to regenerate the derivative CNPs that reference it.
+type GroupMembers struct {
+	// Provider is the cloud provider the group was resolved against, e.g.
+	// "aws", "gcp" or "azure".
+	Provider string `json:"provider"`
+	// GroupID is the provider-specific identifier for the group, e.g. an
+	// AWS security group ID or a GCP instance group URL.
+	GroupID string `json:"groupID"`
+	// IPs are the resolved member addresses, in string form so the value
+	// round-trips through JSON without surprises.
+	IPs []string `json:"ips"`
+	// Version is incremented on every successful resolution and lets
+	// watchers detect whether a derivative CNP was built from stale data.
+	Version uint64 `json:"version"`
+	// ResolvedAt is when the leader last successfully resolved this group.
+	ResolvedAt time.Time `json:"resolvedAt"`
+	// TTL is how long ResolvedAt remains valid. After it elapses, callers
+	// must treat the cached entry as stale and fall back to a direct
+	// provider lookup.
+	TTL time.Duration `json:"ttl"`
+}
+
+// GetKeyName implements store.Key.
+func (g *GroupMembers) GetKeyName() string {
+	return keyName(g.Provider, g.GroupID)
+}
+
+// Marshal implements store.Value.
+func (g *GroupMembers) Marshal() ([]byte, error) {
+	return json.Marshal(g)
+}
+
+// Unmarshal implements store.Value.
+func (g *GroupMembers) Unmarshal(data []byte) error {
+	newGroup := GroupMembers{}
+	if err := json.Unmarshal(data, &newGroup); err != nil {
+		return err
+	}
+	*g = newGroup
+	return nil
+}
+
+// Expired returns true if the resolution is older than its TTL and should
+// no longer be trusted without a fresh provider lookup.
+func (g *GroupMembers) Expired(now time.Time) bool {
+	if g.TTL == 0 {
+		return false
+	}
+	return now.Sub(g.ResolvedAt) > g.TTL
+}
+
+func keyName(provider, groupID string) string {
+	return fmt.Sprintf("%s/%s/%s", groupMembersPrefix, provider, groupID)
+}
+
+// newKey returns an empty GroupMembers to be populated by the kvstore
+// backend when decoding a watch event; it implements store.KeyCreator.
+func newKey() *GroupMembers {
+	return &GroupMembers{}
+}
@@ -0,0 +1,58 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type StoreSuite struct{}
+
+var _ = check.Suite(&StoreSuite{})
+
+func (s *StoreSuite) TestGroupMembersExpired(c *check.C) {
+	now := time.Now()
+
+	noTTL := &GroupMembers{ResolvedAt: now.Add(-time.Hour)}
+	c.Assert(noTTL.Expired(now), check.Equals, false)
+
+	fresh := &GroupMembers{ResolvedAt: now, TTL: DefaultTTL}
+	c.Assert(fresh.Expired(now), check.Equals, false)
+
+	stale := &GroupMembers{ResolvedAt: now.Add(-2 * DefaultTTL), TTL: DefaultTTL}
+	c.Assert(stale.Expired(now), check.Equals, true)
+}
+
+func (s *StoreSuite) TestGroupMembersKeyName(c *check.C) {
+	g := &GroupMembers{Provider: "aws", GroupID: "sg-1234"}
+	c.Assert(g.GetKeyName(), check.Equals, "state/policygroups/v1/aws/sg-1234")
+}
+
+func (s *StoreSuite) TestGroupMembersMarshalUnmarshal(c *check.C) {
+	g := &GroupMembers{Provider: "aws", GroupID: "sg-1234", IPs: []string{"10.0.0.1"}, Version: 2}
+	data, err := g.Marshal()
+	c.Assert(err, check.IsNil)
+
+	var round GroupMembers
+	c.Assert(round.Unmarshal(data), check.IsNil)
+	c.Assert(round.Provider, check.Equals, g.Provider)
+	c.Assert(round.GroupID, check.Equals, g.GroupID)
+	c.Assert(round.Version, check.Equals, g.Version)
+}
@@ -0,0 +1,33 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package watcher subscribes to cloud provider change notifications for
+// ToGroups membership changes and enqueues the affected CNPs for immediate
+// re-derivation, instead of waiting for the next periodic reconciliation.
+// AWSEventBridgeSource is a real implementation, long-polling an SQS queue
+// that an EventBridge rule forwards security-group membership events into;
+// GCPPubSubSource and AzureEventGridSource are not yet implemented and
+// always report ModePolling. Manager falls back to timed polling when no
+// event source is configured for a provider, or when one fails to
+// subscribe, and coalesces bursts of events so that, for example, a
+// 100-instance scale-up triggers a single re-derivation rather than one per
+// instance.
+package watcher
+
+import (
+	"github.com/cilium/cilium/pkg/logging"
+	"github.com/cilium/cilium/pkg/logging/logfields"
+)
+
+var log = logging.DefaultLogger.WithField(logfields.LogSubsys, "policy-groups-watcher")
@@ -0,0 +1,49 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watcher
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Mode describes how a provider's watcher is currently keeping derivative
+// CNPs fresh.
+type Mode float64
+
+const (
+	// ModePolling means no event subscription is configured (or it failed)
+	// for the provider, so membership changes are only picked up on the
+	// next timed poll.
+	ModePolling Mode = 0
+	// ModeEventDriven means the watcher is subscribed to the provider's
+	// change notifications and re-derives affected CNPs as events arrive.
+	ModeEventDriven Mode = 1
+)
+
+// watcherMode exposes, per provider, whether its watcher is currently
+// event-driven or has degraded to polling.
+var watcherMode = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "cilium_operator",
+	Name:      "groups_watcher_mode",
+	Help:      "Mode of the ToGroups watcher per provider (0 = polling, 1 = event-driven)",
+}, []string{"provider"})
+
+func init() {
+	prometheus.MustRegister(watcherMode)
+}
+
+func setMode(provider string, mode Mode) {
+	watcherMode.WithLabelValues(provider).Set(float64(mode))
+}
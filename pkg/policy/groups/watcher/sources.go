@@ -0,0 +1,190 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// errNotConfigured is returned by an EventSource's Subscribe when the
+// provider-specific configuration needed to receive change notifications
+// (an SQS queue URL, a Pub/Sub subscription name, an Event Grid topic
+// endpoint) was not supplied, so Manager should fall back to polling for
+// that provider without logging it as a failure.
+var errNotConfigured = errors.New("event source not configured")
+
+// errSubscribeUnimplemented is returned once a source is configured, for
+// sources that do not yet subscribe to their provider's event stream.
+// Returning it immediately, rather than blocking on ctx forever, matters:
+// Manager.Run only falls back to polling when Subscribe returns a non-nil
+// error, so a source that blocks without ever erroring leaves that
+// provider with neither real events nor the polling fallback.
+var errSubscribeUnimplemented = errors.New("event subscription not yet implemented, falling back to polling")
+
+// sqsReceiveWaitSeconds is how long a single ReceiveMessage long-poll call
+// blocks for a message before returning empty, the maximum SQS allows.
+const sqsReceiveWaitSeconds = 20
+
+// ec2GroupChangeDetail is the JSON body this package expects on each SQS
+// message: an EventBridge rule targeting the queue, matching on EC2
+// security-group membership change events, delivers the event's "detail"
+// object as the message body. Real detail shapes vary by the EventBridge
+// rule's event pattern; operators wiring this up choose a pattern and rule
+// that populates groupId, e.g. by matching on EC2 instance state-change
+// events and looking up the instance's security groups in the rule's input
+// transformer.
+type ec2GroupChangeDetail struct {
+	GroupID string `json:"groupId"`
+}
+
+// AWSEventBridgeSource subscribes to EC2 security group membership change
+// events by long-polling an SQS queue that an EventBridge rule forwards
+// those events into. EventBridge has no push transport suitable for a
+// long-running consumer like cilium-operator, so SQS is the target the rule
+// is expected to be configured with.
+type AWSEventBridgeSource struct {
+	// QueueURL is the SQS queue that the EventBridge rule delivers
+	// security-group membership change events into. Left empty, the source
+	// reports itself as unconfigured and Manager falls back to polling.
+	QueueURL string
+
+	// newClient builds the SQS client used to receive and delete messages;
+	// overridable in tests. Left nil, it builds a real client from the
+	// ambient AWS session on first use.
+	newClient func() (*sqs.SQS, error)
+}
+
+// Provider implements EventSource.
+func (s *AWSEventBridgeSource) Provider() string { return "aws" }
+
+// Subscribe implements EventSource. It long-polls QueueURL until ctx is
+// cancelled, calling handler once per security-group membership change
+// event found in a message body, and deletes each message it successfully
+// processes so it is not redelivered.
+func (s *AWSEventBridgeSource) Subscribe(ctx context.Context, handler func(GroupKey)) error {
+	if s.QueueURL == "" {
+		return errNotConfigured
+	}
+
+	newClient := s.newClient
+	if newClient == nil {
+		newClient = newSQSClient
+	}
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		out, err := client.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            aws.String(s.QueueURL),
+			MaxNumberOfMessages: aws.Int64(10),
+			WaitTimeSeconds:     aws.Int64(sqsReceiveWaitSeconds),
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+
+		for _, message := range out.Messages {
+			if message.Body == nil {
+				continue
+			}
+			var detail ec2GroupChangeDetail
+			if err := json.Unmarshal([]byte(*message.Body), &detail); err != nil || detail.GroupID == "" {
+				log.WithError(err).Warning("Skipping unparsable EventBridge message")
+				continue
+			}
+
+			handler(GroupKey{Provider: "aws", GroupID: detail.GroupID})
+
+			if message.ReceiptHandle != nil {
+				if _, err := client.DeleteMessageWithContext(ctx, &sqs.DeleteMessageInput{
+					QueueUrl:      aws.String(s.QueueURL),
+					ReceiptHandle: message.ReceiptHandle,
+				}); err != nil {
+					log.WithError(err).Warning("Failed to delete processed EventBridge message")
+				}
+			}
+		}
+	}
+}
+
+func newSQSClient() (*sqs.SQS, error) {
+	sess, err := session.NewSessionWithOptions(session.Options{SharedConfigState: session.SharedConfigEnable})
+	if err != nil {
+		return nil, err
+	}
+	return sqs.New(sess), nil
+}
+
+// GCPPubSubSource subscribes to a Pub/Sub subscription fed by a Cloud
+// Logging sink on instance group / managed instance group update events.
+//
+// TODO: Subscribe is unimplemented; it only validates configuration today
+// and returns errSubscribeUnimplemented so Manager falls back to polling.
+// See AWSEventBridgeSource.Subscribe for the shape a real implementation
+// should take: a transport that can be long-polled from a single
+// long-running goroutine, the same way SQS is used for AWS.
+type GCPPubSubSource struct {
+	// Subscription is the fully qualified Pub/Sub subscription name. Left
+	// empty, the source reports itself as unconfigured.
+	Subscription string
+}
+
+// Provider implements EventSource.
+func (s *GCPPubSubSource) Provider() string { return "gcp" }
+
+// Subscribe implements EventSource. See the TODO on GCPPubSubSource.
+func (s *GCPPubSubSource) Subscribe(ctx context.Context, handler func(GroupKey)) error {
+	if s.Subscription == "" {
+		return errNotConfigured
+	}
+	return errSubscribeUnimplemented
+}
+
+// AzureEventGridSource subscribes to an Event Grid topic fed by VMSS scale
+// events.
+//
+// TODO: Subscribe is unimplemented; see the TODO on GCPPubSubSource for what
+// a real implementation should look like.
+type AzureEventGridSource struct {
+	// TopicEndpoint is the Event Grid webhook/queue endpoint cilium-operator
+	// reads from. Left empty, the source reports itself as unconfigured.
+	TopicEndpoint string
+}
+
+// Provider implements EventSource.
+func (s *AzureEventGridSource) Provider() string { return "azure" }
+
+// Subscribe implements EventSource. See the TODO on AzureEventGridSource.
+func (s *AzureEventGridSource) Subscribe(ctx context.Context, handler func(GroupKey)) error {
+	if s.TopicEndpoint == "" {
+		return errNotConfigured
+	}
+	return errSubscribeUnimplemented
+}
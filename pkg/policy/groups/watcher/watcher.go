@@ -0,0 +1,201 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watcher
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// debounceWindow is how long the Manager waits after the most recent change
+// notification, with no further one arriving, before flushing the coalesced
+// set of affected groups. A scale-up that fires one event per instance
+// collapses into a single flush as long as no gap between its events is
+// longer than this window and the burst ends within maxDebounceWait.
+const debounceWindow = 2 * time.Second
+
+// maxDebounceWait bounds how long enqueue can keep pushing a flush out.
+// Without a cap, a provider emitting events more often than every
+// debounceWindow would defer flush indefinitely and onRefresh would never
+// run, no matter how much membership had actually changed. Once this much
+// time has passed since the first still-pending event, the next enqueue
+// flushes immediately instead of resetting the window again.
+const maxDebounceWait = 30 * time.Second
+
+// GroupKey identifies a single resolved group whose membership changed.
+type GroupKey struct {
+	Provider string
+	GroupID  string
+}
+
+// EventSource subscribes to a single cloud provider's change notifications.
+// Implementations call the handler passed to Subscribe once per
+// notification; Manager takes care of coalescing bursts before acting on
+// them.
+type EventSource interface {
+	// Provider returns the provider this source watches, e.g. "aws".
+	Provider() string
+
+	// Subscribe blocks, delivering affected groups to handler as events
+	// arrive, until ctx is cancelled or subscribing fails. A non-nil error
+	// tells the Manager to fall back to polling for this provider.
+	Subscribe(ctx context.Context, handler func(GroupKey)) error
+}
+
+// Manager runs one EventSource per configured provider, degrading to timed
+// polling for any provider whose source is not configured or whose
+// subscription fails, and coalesces bursts of events into a single refresh
+// callback per debounce window.
+type Manager struct {
+	pollInterval time.Duration
+	onRefresh    func(affected []GroupKey)
+
+	mutex        sync.Mutex
+	pending      map[GroupKey]struct{}
+	flushSet     *time.Timer
+	firstPending time.Time
+}
+
+// NewManager creates a Manager. onRefresh is called with the de-duplicated
+// set of groups that changed since the last flush, at most once per
+// debounce window; it is expected to enqueue re-derivation of every CNP
+// that references one of the affected groups.
+func NewManager(pollInterval time.Duration, onRefresh func(affected []GroupKey)) *Manager {
+	return &Manager{
+		pollInterval: pollInterval,
+		onRefresh:    onRefresh,
+		pending:      map[GroupKey]struct{}{},
+	}
+}
+
+// Run starts one goroutine per source, and a polling fallback for any
+// provider in polledProviders that has no corresponding source (or whose
+// source later fails). Run returns once ctx is cancelled.
+func (m *Manager) Run(ctx context.Context, sources []EventSource, polledProviders []string) {
+	watched := map[string]struct{}{}
+
+	var wg sync.WaitGroup
+	for _, source := range sources {
+		watched[source.Provider()] = struct{}{}
+		wg.Add(1)
+		go func(source EventSource) {
+			defer wg.Done()
+			m.runSource(ctx, source)
+		}(source)
+	}
+
+	for _, provider := range polledProviders {
+		if _, ok := watched[provider]; ok {
+			continue
+		}
+		wg.Add(1)
+		go func(provider string) {
+			defer wg.Done()
+			m.poll(ctx, provider)
+		}(provider)
+	}
+
+	wg.Wait()
+}
+
+func (m *Manager) runSource(ctx context.Context, source EventSource) {
+	provider := source.Provider()
+	setMode(provider, ModeEventDriven)
+
+	err := source.Subscribe(ctx, func(key GroupKey) {
+		m.enqueue(key)
+	})
+	if err != nil && ctx.Err() == nil {
+		if err == errNotConfigured {
+			log.WithField("provider", provider).
+				Info("Event source not configured, falling back to polling")
+		} else {
+			log.WithError(err).WithField("provider", provider).
+				Warning("Event subscription failed, falling back to polling")
+		}
+		setMode(provider, ModePolling)
+		m.poll(ctx, provider)
+	}
+}
+
+func (m *Manager) poll(ctx context.Context, provider string) {
+	setMode(provider, ModePolling)
+
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// A bare poll tick has no specific group to report; it simply
+			// asks the caller to re-derive everything for this provider.
+			m.enqueue(GroupKey{Provider: provider})
+		}
+	}
+}
+
+// enqueue records that key changed and schedules a flush after
+// debounceWindow, restarting the window on every call so a sustained burst
+// keeps being coalesced until it quiets down. If events keep arriving for
+// longer than maxDebounceWait since the first one still pending, enqueue
+// flushes immediately instead of resetting again, so continuous churn
+// (not just a one-time burst) still produces periodic refreshes.
+func (m *Manager) enqueue(key GroupKey) {
+	m.mutex.Lock()
+
+	m.pending[key] = struct{}{}
+	if m.firstPending.IsZero() {
+		m.firstPending = time.Now()
+	}
+
+	if m.flushSet == nil {
+		m.flushSet = time.AfterFunc(debounceWindow, m.flush)
+		m.mutex.Unlock()
+		return
+	}
+
+	if time.Since(m.firstPending) >= maxDebounceWait {
+		m.flushSet.Stop()
+		m.flushSet = nil
+		m.mutex.Unlock()
+		m.flush()
+		return
+	}
+
+	// Reset, rather than leaving the existing timer armed, so a sustained
+	// burst keeps pushing the flush out instead of firing partway through
+	// it; flush only runs once the window has passed with no new event, or
+	// once maxDebounceWait is hit above.
+	m.flushSet.Reset(debounceWindow)
+	m.mutex.Unlock()
+}
+
+func (m *Manager) flush() {
+	m.mutex.Lock()
+	affected := make([]GroupKey, 0, len(m.pending))
+	for key := range m.pending {
+		affected = append(affected, key)
+	}
+	m.pending = map[GroupKey]struct{}{}
+	m.flushSet = nil
+	m.firstPending = time.Time{}
+	m.mutex.Unlock()
+
+	if len(affected) > 0 {
+		m.onRefresh(affected)
+	}
+}
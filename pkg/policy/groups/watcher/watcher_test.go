@@ -0,0 +1,173 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watcher
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type WatcherSuite struct{}
+
+var _ = check.Suite(&WatcherSuite{})
+
+// collectingManager builds a Manager whose onRefresh records every flush it
+// is given, for assertions on coalescing.
+func collectingManager() (*Manager, *[][]GroupKey, *sync.Mutex) {
+	var mutex sync.Mutex
+	var flushes [][]GroupKey
+	m := NewManager(time.Hour, func(affected []GroupKey) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		flushes = append(flushes, affected)
+	})
+	return m, &flushes, &mutex
+}
+
+func (s *WatcherSuite) TestEnqueueCoalescesBurstIntoSingleFlush(c *check.C) {
+	m, flushes, mutex := collectingManager()
+
+	key := GroupKey{Provider: "aws", GroupID: "sg-1"}
+	for i := 0; i < 5; i++ {
+		m.enqueue(key)
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	c.Assert(waitForFlushes(mutex, flushes, 1, debounceWindow+2*time.Second), check.Equals, true)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	c.Assert(len(*flushes), check.Equals, 1)
+	c.Assert((*flushes)[0], check.DeepEquals, []GroupKey{key})
+}
+
+func (s *WatcherSuite) TestEnqueueResetsWindowOnEachCall(c *check.C) {
+	// A burst lasting longer than debounceWindow, with each enqueue call
+	// restarting the timer, must not flush before it ends and must still
+	// collapse to one flush: a timer armed only once at the first event
+	// would fire partway through the burst.
+	m, flushes, mutex := collectingManager()
+
+	key := GroupKey{Provider: "aws", GroupID: "sg-1"}
+	deadline := time.Now().Add(debounceWindow + debounceWindow/2)
+	for time.Now().Before(deadline) {
+		m.enqueue(key)
+		time.Sleep(debounceWindow / 4)
+	}
+
+	mutex.Lock()
+	flushedMidBurst := len(*flushes)
+	mutex.Unlock()
+	c.Assert(flushedMidBurst, check.Equals, 0)
+
+	c.Assert(waitForFlushes(mutex, flushes, 1, debounceWindow+2*time.Second), check.Equals, true)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	c.Assert(len(*flushes), check.Equals, 1)
+}
+
+func (s *WatcherSuite) TestEnqueueFlushesImmediatelyOnceMaxWaitElapsed(c *check.C) {
+	// Continuous churn (events arriving faster than every debounceWindow,
+	// indefinitely, not just a one-time burst) must still eventually flush:
+	// simulate that by backdating firstPending past maxDebounceWait rather
+	// than waiting maxDebounceWait in real time.
+	m, flushes, mutex := collectingManager()
+
+	key := GroupKey{Provider: "aws", GroupID: "sg-1"}
+	m.enqueue(key)
+
+	m.mutex.Lock()
+	m.firstPending = time.Now().Add(-maxDebounceWait - time.Second)
+	m.mutex.Unlock()
+
+	m.enqueue(GroupKey{Provider: "aws", GroupID: "sg-2"})
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	c.Assert(len(*flushes), check.Equals, 1)
+	c.Assert(len((*flushes)[0]), check.Equals, 2)
+}
+
+func (s *WatcherSuite) TestEnqueueDedupesRepeatedKeys(c *check.C) {
+	m, flushes, mutex := collectingManager()
+
+	key := GroupKey{Provider: "aws", GroupID: "sg-1"}
+	m.enqueue(key)
+	m.enqueue(key)
+	m.enqueue(key)
+
+	c.Assert(waitForFlushes(mutex, flushes, 1, debounceWindow+2*time.Second), check.Equals, true)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	c.Assert(len((*flushes)[0]), check.Equals, 1)
+}
+
+func waitForFlushes(mutex *sync.Mutex, flushes *[][]GroupKey, n int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		mutex.Lock()
+		got := len(*flushes)
+		mutex.Unlock()
+		if got >= n {
+			return true
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return false
+}
+
+// stubSource returns err immediately, mirroring how the fixed sources.go
+// sources fail fast instead of blocking on ctx.Done() forever.
+type stubSource struct {
+	provider string
+	err      error
+}
+
+func (s *stubSource) Provider() string { return s.provider }
+func (s *stubSource) Subscribe(ctx context.Context, handler func(GroupKey)) error {
+	return s.err
+}
+
+func (s *WatcherSuite) TestRunSourceFallsBackToPollingOnSubscribeError(c *check.C) {
+	m, flushes, mutex := collectingManager()
+	// Poll ticks closer together than debounceWindow would keep restarting
+	// the window and delay the flush until maxDebounceWait, so tick just
+	// once per window.
+	m.pollInterval = debounceWindow + debounceWindow/4
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	source := &stubSource{provider: "aws", err: errSubscribeUnimplemented}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		m.runSource(ctx, source)
+	}()
+
+	c.Assert(waitForFlushes(mutex, flushes, 1, m.pollInterval+debounceWindow+2*time.Second), check.Equals, true)
+	cancel()
+	wg.Wait()
+}
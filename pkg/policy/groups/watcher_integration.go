@@ -0,0 +1,64 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package groups
+
+import (
+	"context"
+	"time"
+
+	"github.com/cilium/cilium/pkg/k8s"
+	"github.com/cilium/cilium/pkg/policy/groups/watcher"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultPollInterval is used for any provider whose event source is not
+// configured or whose subscription fails.
+const defaultPollInterval = 5 * time.Minute
+
+// StartGroupsWatcher subscribes to cloud provider change notifications
+// through sources and re-derives affected CNPs as they arrive, falling back
+// to polling every defaultPollInterval for any provider without a working
+// subscription. It blocks until ctx is cancelled, so callers should run it
+// in its own goroutine.
+func StartGroupsWatcher(ctx context.Context, sources []watcher.EventSource, polledProviders []string) {
+	manager := watcher.NewManager(defaultPollInterval, refreshDerivativeCNPs)
+	manager.Run(ctx, sources, polledProviders)
+}
+
+// refreshDerivativeCNPs is called with the de-duplicated set of groups that
+// changed since the last flush. ToGroups does not yet carry a
+// provider+group-identifier selector (see GroupSpec in the providers
+// package), so affected cannot be mapped back to the specific CNPs that
+// reference each group; as a conservative fallback every CNP that requires
+// a derivative is re-derived. Once ToGroups is extended, this should only
+// re-derive the CNPs referencing one of the affected groups.
+func refreshDerivativeCNPs(affected []watcher.GroupKey) {
+	log.WithField("affectedGroups", len(affected)).
+		Info("Cloud provider group membership changed, re-deriving CNPs")
+
+	list, err := k8s.CiliumClient().CiliumV2().CiliumNetworkPolicies(v1.NamespaceAll).List(v1.ListOptions{})
+	if err != nil {
+		log.WithError(err).Error("Cannot list CiliumNetworkPolicies to re-derive ToGroups rules")
+		return
+	}
+
+	for i := range list.Items {
+		cnp := &list.Items[i]
+		if cnp.RequiresDerivative() {
+			AddDerivativeCNPIfNeeded(cnp)
+		}
+	}
+}